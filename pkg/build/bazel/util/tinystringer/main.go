@@ -13,15 +13,21 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
 )
 
 var (
-	lineComment                                                             bool
+	lineComment, bitflags, marshalText, marshalJSON, sqlValuerScanner       bool
+	parseFunc, caseInsensitive, exhaustiveCheck, watch                      bool
 	output, typeName, trimPrefix, stringToValueMapName, enumValuesSliceName string
 	allowedIntegerTypes                                                     = []string{
 		"byte",
@@ -37,21 +43,45 @@ var (
 		"uint32",
 		"uint64",
 	}
+	// scanIntegerTypes is allowedIntegerTypes with byte/rune dropped, since
+	// they're aliases of uint8/int32 and would otherwise produce duplicate
+	// cases in the generated Scan type switch.
+	scanIntegerTypes = []string{
+		"int",
+		"int8",
+		"int16",
+		"int32",
+		"int64",
+		"uint",
+		"uint8",
+		"uint16",
+		"uint32",
+		"uint64",
+	}
 )
 
 type tinyStringer struct {
 	files                                                                   []string
 	typeName, trimPrefix, output, stringToValueMapName, enumValuesSliceName string
-	lineComment                                                             bool
+	lineComment, bitflags, marshalText, marshalJSON, sqlValuerScanner       bool
+	parseFunc, caseInsensitive, exhaustiveCheck, watch                      bool
 }
 
 func init() {
 	flag.StringVar(&stringToValueMapName, "stringtovaluemapname", "", "if set, also create a map of enum name -> value of the given name")
 	flag.StringVar(&enumValuesSliceName, "enumvaluesslicename", "", "if set, also create a slice of all enum values of the given name")
 	flag.StringVar(&output, "output", "", "name of output file; default srcdir/<type>_string.go")
-	flag.StringVar(&typeName, "type", "", "the type for which to generate output")
+	flag.StringVar(&typeName, "type", "", "comma-separated list of types for which to generate output")
 	flag.StringVar(&trimPrefix, "trimprefix", "", "trim the given prefix from generated names")
 	flag.BoolVar(&lineComment, "linecomment", false, "use line comment text as printed text when present")
+	flag.BoolVar(&bitflags, "bitflags", false, "generate a String() that decomposes the receiver as an OR of the known single-bit constants, for flag-style enums built with 1 << iota")
+	flag.BoolVar(&marshalText, "marshaltext", false, "generate MarshalText/UnmarshalText implementing encoding.TextMarshaler/TextUnmarshaler")
+	flag.BoolVar(&marshalJSON, "marshaljson", false, "generate MarshalJSON/UnmarshalJSON implementing json.Marshaler/Unmarshaler")
+	flag.BoolVar(&sqlValuerScanner, "sql", false, "generate Value/Scan implementing database/sql/driver.Valuer and database/sql.Scanner")
+	flag.BoolVar(&parseFunc, "parsefunc", false, "generate a Parse<Type> function that looks up a constant by its printed name")
+	flag.BoolVar(&caseInsensitive, "caseinsensitive", false, "make Parse<Type> match names case-insensitively; has no effect without -parsefunc")
+	flag.BoolVar(&exhaustiveCheck, "exhaustivecheck", false, "emit a private map literal listing every known constant, so that removing or renaming one without regenerating fails to compile")
+	flag.BoolVar(&watch, "watch", false, "after generating once, keep running and regenerate whenever an input file changes, until interrupted")
 }
 
 func main() {
@@ -65,21 +95,48 @@ func doMain() error {
 	if typeName == "" {
 		return errors.New("must provide --type")
 	}
-	return tinyStringer{
+	s := tinyStringer{
+		bitflags:             bitflags,
+		caseInsensitive:      caseInsensitive,
 		enumValuesSliceName:  enumValuesSliceName,
+		exhaustiveCheck:      exhaustiveCheck,
 		files:                flag.Args(),
 		lineComment:          lineComment,
+		marshalJSON:          marshalJSON,
+		marshalText:          marshalText,
 		output:               output,
+		parseFunc:            parseFunc,
+		sqlValuerScanner:     sqlValuerScanner,
 		stringToValueMapName: stringToValueMapName,
 		typeName:             typeName,
 		trimPrefix:           trimPrefix,
-	}.stringify()
+		watch:                watch,
+	}
+	if err := s.stringify(); err != nil {
+		return err
+	}
+	if !s.watch {
+		return nil
+	}
+	return s.watchAndRegenerate()
 }
 
 func (s tinyStringer) stringify() error {
 	if len(s.files) == 0 {
 		return errors.New("must provide at least one file argument")
 	}
+	typeNames := strings.Split(s.typeName, ",")
+	for i := range typeNames {
+		typeNames[i] = strings.TrimSpace(typeNames[i])
+	}
+	if len(typeNames) > 1 {
+		if s.stringToValueMapName != "" {
+			return fmt.Errorf("--stringtovaluemapname cannot be used with multiple --type values, since the name would be reused for every type")
+		}
+		if s.enumValuesSliceName != "" {
+			return fmt.Errorf("--enumvaluesslicename cannot be used with multiple --type values, since the name would be reused for every type")
+		}
+	}
 	// Make sure all input files are in the same package.
 	var srcDir, whichFile string
 	for _, file := range s.files {
@@ -94,149 +151,571 @@ func (s tinyStringer) stringify() error {
 		}
 	}
 	if s.output == "" {
-		s.output = filepath.Join(srcDir, strings.ToLower(s.typeName)+"_string.go")
+		lowered := make([]string, len(typeNames))
+		for i, t := range typeNames {
+			lowered[i] = strings.ToLower(t)
+		}
+		s.output = filepath.Join(srcDir, strings.Join(lowered, "_")+"_string.go")
 	}
 
 	parsedFiles, pkgName, err := parseAllFiles(s.files)
 	if err != nil {
 		return err
 	}
-	if err := validateType(parsedFiles, s.typeName); err != nil {
-		return err
-	}
 
-	inOrder, nameToInt, nameToPrinted, err := s.computeConstantValues(parsedFiles)
+	// Produce s.output.
+	outputFile, err := os.Create(s.output)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		_ = outputFile.Close()
+	}()
+	fmt.Fprintf(outputFile, "// Code generated by \"stringer\"; DO NOT EDIT.\n\npackage %s\n\n", pkgName)
+	importSet := map[string]struct{}{"strconv": {}}
+	if s.bitflags {
+		importSet["strings"] = struct{}{}
+	}
+	if s.marshalText || s.marshalJSON || s.sqlValuerScanner {
+		importSet["fmt"] = struct{}{}
+	}
+	if s.marshalJSON {
+		importSet["encoding/json"] = struct{}{}
+	}
+	if s.sqlValuerScanner {
+		importSet["database/sql/driver"] = struct{}{}
+	}
+	if s.parseFunc {
+		importSet["fmt"] = struct{}{}
+		if s.caseInsensitive {
+			importSet["strings"] = struct{}{}
+		}
+	}
+	imports := make([]string, 0, len(importSet))
+	for imp := range importSet {
+		imports = append(imports, imp)
+	}
+	slices.Sort(imports)
+	if len(imports) == 1 {
+		fmt.Fprintf(outputFile, "import %q\n", imports[0])
+	} else {
+		fmt.Fprint(outputFile, "import (\n")
+		for _, imp := range imports {
+			fmt.Fprintf(outputFile, "\t%q\n", imp)
+		}
+		fmt.Fprint(outputFile, ")\n")
+	}
+	for _, typeName := range typeNames {
+		if err := validateType(parsedFiles, typeName); err != nil {
+			return err
+		}
+		inOrder, nameToInt, nameToPrinted, err := s.computeConstantValues(parsedFiles, typeName)
+		if err != nil {
+			return err
+		}
+		if len(nameToInt) == 0 || len(nameToPrinted) == 0 {
+			return fmt.Errorf("did not find enough constant values for type %s", typeName)
+		}
+		ts := s
+		ts.typeName = typeName
+
+		fmt.Fprintf(outputFile, `
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+`)
+		for _, constName := range inOrder {
+			if constName == "_" {
+				continue
+			}
+			minus := "-"
+			if nameToInt[constName] < 0 {
+				// Implement the behavior of gofmt, which wants no space
+				// between the operands unless the number on the right
+				// is negative (would probably trigger some parse error).
+				minus = " - "
+			}
+			fmt.Fprintf(outputFile, "	_ = x[%s%s%d]\n", constName, minus, nameToInt[constName])
+		}
+		receiverVar := "i"
+		if _, ok := nameToInt[receiverVar]; ok {
+			receiverVar = "_i"
+			if _, ok := nameToInt[receiverVar]; ok {
+				return fmt.Errorf("don't know how to choose a receiver variable because %s is a constant name", receiverVar)
+			}
+		}
+		fmt.Fprintf(outputFile, `}
 
-	if len(nameToInt) == 0 || len(nameToPrinted) == 0 {
-		return fmt.Errorf("did not find enough constant values for type %s", s.typeName)
+func (%s %s) String() string {
+`, receiverVar, typeName)
+		if ts.bitflags {
+			ts.writeBitflagsString(outputFile, receiverVar, inOrder, nameToInt, nameToPrinted)
+		} else {
+			fmt.Fprintf(outputFile, `	switch %s {
+`, receiverVar)
+			seen := make(map[int]struct{})
+			for _, constName := range inOrder {
+				if constName == "_" {
+					continue
+				}
+				if _, ok := seen[nameToInt[constName]]; ok {
+					continue
+				}
+				fmt.Fprintf(outputFile, `	case %s:
+		return "%s"
+`, constName, nameToPrinted[constName])
+				seen[nameToInt[constName]] = struct{}{}
+			}
+			fmt.Fprintf(outputFile, `	default:
+		return "%s(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
+}
+`, typeName)
+		}
+		nameToValueMapName := ts.stringToValueMapName
+		needsPrivateNameToValueMap := nameToValueMapName == "" && (ts.marshalText || ts.marshalJSON || ts.sqlValuerScanner || ts.parseFunc)
+		if needsPrivateNameToValueMap {
+			nameToValueMapName = "_" + strings.ToLower(typeName) + "NameToValue"
+		}
+		if nameToValueMapName != "" {
+			ts.writeNameToValueMap(outputFile, nameToValueMapName, inOrder, nameToInt, nameToPrinted)
+		}
+		enumValuesSliceName := ts.enumValuesSliceName
+		needsPrivateEnumValuesSlice := enumValuesSliceName == "" && ts.sqlValuerScanner
+		if needsPrivateEnumValuesSlice {
+			enumValuesSliceName = "_" + strings.ToLower(typeName) + "Values"
+		}
+		if enumValuesSliceName != "" {
+			ts.writeEnumValuesSlice(outputFile, enumValuesSliceName, inOrder, nameToInt, nameToPrinted)
+		}
 
-	// Produce s.output.
-	outputFile, err := os.Create(s.output)
+		if ts.marshalText || ts.marshalJSON {
+			ts.writeTextMarshaling(outputFile, nameToValueMapName, inOrder, nameToPrinted)
+		}
+		if ts.marshalJSON {
+			ts.writeJSONMarshaling(outputFile, nameToValueMapName)
+		}
+		if ts.sqlValuerScanner {
+			ts.writeSQLValuerScanner(outputFile, nameToValueMapName, enumValuesSliceName)
+		}
+		if ts.parseFunc {
+			ts.writeParseFunc(outputFile, nameToValueMapName)
+		}
+		if ts.exhaustiveCheck {
+			ts.writeExhaustiveCheck(outputFile, inOrder, nameToInt)
+		}
+	}
+
+	return nil
+}
+
+// watchAndRegenerate re-runs stringify whenever one of s.files changes. It
+// watches srcDir rather than the files directly so that editors which save
+// by writing a new file and renaming it over the original (which replaces
+// the inode a per-file watch would be tracking) are still picked up.
+// Coalesced events are debounced by ~200ms so that a single atomic-rename
+// save only triggers one regeneration. Watch errors are logged rather than
+// fatal, and the loop exits cleanly on SIGINT.
+func (s tinyStringer) watchAndRegenerate() error {
+	srcDir := filepath.Dir(s.files[0])
+	watched := make(map[string]struct{}, len(s.files))
+	for _, file := range s.files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		watched[abs] = struct{}{}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
 	}
 	defer func() {
-		_ = outputFile.Close()
+		_ = watcher.Close()
 	}()
-	fmt.Fprintf(outputFile, `// Code generated by "stringer"; DO NOT EDIT.
+	if err := watcher.Add(srcDir); err != nil {
+		return err
+	}
 
-package %s
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
 
-import "strconv"
+	const debounceDelay = 200 * time.Millisecond
+	pending := make(chan struct{}, 1)
+	var debounce *time.Timer
+	defer func() {
+		if debounce != nil {
+			debounce.Stop()
+		}
+	}()
 
-func _() {
-	// An "invalid array index" compiler error signifies that the constant values have changed.
-	// Re-run the stringer command to generate them again.
-	var x [1]struct{}
-`, pkgName)
-	for _, constName := range inOrder {
+	for {
+		select {
+		case <-sigCh:
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			abs, err := filepath.Abs(event.Name)
+			if err != nil {
+				log.Printf("tinystringer: %v", err)
+				continue
+			}
+			if _, ok := watched[abs]; !ok {
+				continue
+			}
+			if debounce == nil {
+				debounce = time.AfterFunc(debounceDelay, func() { pending <- struct{}{} })
+			} else {
+				debounce.Reset(debounceDelay)
+			}
+		case watchErr, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("tinystringer: watch error: %v", watchErr)
+		case <-pending:
+			if err := s.stringify(); err != nil {
+				log.Printf("tinystringer: regeneration failed: %v", err)
+			}
+		}
+	}
+}
+
+// writeEnumValuesSlice writes a []<Type> literal named sliceName holding
+// every distinct value of the enum, sorted by printed name. It's shared by
+// the user-requested --enumvaluesslicename slice and by the private
+// membership table generated for --sql (and, later, other features that
+// need to check whether a value is one of the type's known constants).
+func (s tinyStringer) writeEnumValuesSlice(
+	outputFile *os.File,
+	sliceName string,
+	inOrder []string,
+	nameToInt map[string]int,
+	nameToPrinted map[string]string,
+) {
+	seen := make(map[int]struct{})
+	fmt.Fprintf(outputFile, `
+var %s = []%s{
+`, sliceName, s.typeName)
+	inLexicographicOrder := make([]string, len(inOrder))
+	copy(inLexicographicOrder, inOrder)
+	// Clear duplicates, select the first one in order.
+	i := 0
+	for i < len(inLexicographicOrder) {
+		constName := inLexicographicOrder[i]
+		if _, ok := seen[nameToInt[constName]]; ok {
+			inLexicographicOrder = append(inLexicographicOrder[:i], inLexicographicOrder[i+1:]...)
+		} else {
+			i += 1
+			seen[nameToInt[constName]] = struct{}{}
+		}
+	}
+	slices.SortFunc(inLexicographicOrder, func(a, b string) int {
+		return cmp.Compare(nameToPrinted[a], nameToPrinted[b])
+	})
+	seen = make(map[int]struct{})
+	for _, constName := range inLexicographicOrder {
 		if constName == "_" {
 			continue
 		}
-		minus := "-"
-		if nameToInt[constName] < 0 {
-			// Implement the behavior of gofmt, which wants no space
-			// between the operands unless the number on the right
-			// is negative (would probably trigger some parse error).
-			minus = " - "
+		if _, ok := seen[nameToInt[constName]]; ok {
+			continue
 		}
-		fmt.Fprintf(outputFile, "	_ = x[%s%s%d]\n", constName, minus, nameToInt[constName])
+		fmt.Fprintf(outputFile, `	%s,
+`, constName)
+		seen[nameToInt[constName]] = struct{}{}
 	}
-	receiverVar := "i"
-	if _, ok := nameToInt[receiverVar]; ok {
-		receiverVar = "_i"
-		if _, ok := nameToInt[receiverVar]; ok {
-			return fmt.Errorf("don't know how to choose a receiver variable because %s is a constant name", receiverVar)
+	fmt.Fprintf(outputFile, `}
+`)
+}
+
+// writeNameToValueMap writes a map[string]<Type> literal named mapName,
+// mapping each constant's printed name to its value. It's shared by the
+// user-requested --stringtovaluemapname map and by the private lookup table
+// generated for --marshaltext, --marshaljson, --sql and --parsefunc (the
+// features that parse a printed name back into a value) so that all of them
+// stay in sync with String().
+func (s tinyStringer) writeNameToValueMap(
+	outputFile *os.File,
+	mapName string,
+	inOrder []string,
+	nameToInt map[string]int,
+	nameToPrinted map[string]string,
+) {
+	fmt.Fprintf(outputFile, `
+var %s = map[string]%s{
+`, mapName, s.typeName)
+	// Figure out the length of the longest const name to see how
+	// much we need to pad it out.
+	var maxLen int
+	for _, constName := range inOrder {
+		if len(nameToPrinted[constName]) > maxLen {
+			maxLen = len(nameToPrinted[constName])
 		}
 	}
+	for _, constName := range inOrder {
+		if constName == "_" {
+			continue
+		}
+		padding := strings.Repeat(" ", 1+maxLen-len(nameToPrinted[constName]))
+		fmt.Fprintf(outputFile, `	"%s":%s%d,
+`, nameToPrinted[constName], padding, nameToInt[constName])
+	}
 	fmt.Fprintf(outputFile, `}
+`)
+}
 
-func (%s %s) String() string {
-	switch %s {
-`, receiverVar, s.typeName, receiverVar)
-	seen := make(map[int]struct{})
+// writeTextMarshaling writes MarshalText/UnmarshalText implementing
+// encoding.TextMarshaler/TextUnmarshaler, parsing names out of
+// nameToValueMapName (built from the same printed names String() returns).
+func (s tinyStringer) writeTextMarshaling(
+	outputFile *os.File, nameToValueMapName string, inOrder []string, nameToPrinted map[string]string,
+) {
+	var validValues []string
+	seenPrinted := make(map[string]struct{})
 	for _, constName := range inOrder {
 		if constName == "_" {
 			continue
 		}
-		if _, ok := seen[nameToInt[constName]]; ok {
+		printed := nameToPrinted[constName]
+		if _, ok := seenPrinted[printed]; ok {
 			continue
 		}
+		seenPrinted[printed] = struct{}{}
+		validValues = append(validValues, printed)
+	}
+	fmt.Fprintf(outputFile, `
+func (i %s) MarshalText() ([]byte, error) {
+	return []byte(i.String()), nil
+}
+
+func (i *%s) UnmarshalText(text []byte) error {
+	v, ok := %s[string(text)]
+	if !ok {
+		return fmt.Errorf("unknown %s value %%q; valid values are: %s", text)
+	}
+	*i = v
+	return nil
+}
+`, s.typeName, s.typeName, nameToValueMapName, s.typeName, strings.Join(validValues, ", "))
+}
+
+// writeJSONMarshaling writes MarshalJSON/UnmarshalJSON implementing
+// json.Marshaler/Unmarshaler. UnmarshalJSON accepts a JSON string by
+// delegating to UnmarshalText, and a JSON number by casting it to the enum
+// type and checking it against nameToValueMapName's values.
+func (s tinyStringer) writeJSONMarshaling(outputFile *os.File, nameToValueMapName string) {
+	fmt.Fprintf(outputFile, `
+func (i %s) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.String())
+}
+
+func (i *%s) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		return i.UnmarshalText([]byte(s))
+	}
+	var n int64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	v := %s(n)
+	for _, known := range %s {
+		if known == v {
+			*i = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s value %%d", n)
+}
+`, s.typeName, s.typeName, s.typeName, nameToValueMapName, s.typeName)
+}
+
+// writeSQLValuerScanner writes Value/Scan implementing
+// database/sql/driver.Valuer and database/sql.Scanner. Value prints the
+// constant's name, same as String(). Scan accepts a string, []byte, or any
+// integer type: strings are looked up in nameToValueMapName, and integers
+// are cast directly to the enum type and checked for membership in
+// enumValuesSliceName.
+func (s tinyStringer) writeSQLValuerScanner(
+	outputFile *os.File, nameToValueMapName, enumValuesSliceName string,
+) {
+	fmt.Fprintf(outputFile, `
+func (i %s) Value() (driver.Value, error) {
+	return i.String(), nil
+}
+
+func (i *%s) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		return i.scanName(v)
+	case []byte:
+		return i.scanName(string(v))
+`, s.typeName, s.typeName)
+	for _, intType := range scanIntegerTypes {
 		fmt.Fprintf(outputFile, `	case %s:
-		return "%s"
-`, constName, nameToPrinted[constName])
-		seen[nameToInt[constName]] = struct{}{}
+		return i.scanValue(%s(v))
+`, intType, s.typeName)
 	}
 	fmt.Fprintf(outputFile, `	default:
-		return "%s(" + strconv.FormatInt(int64(i), 10) + ")"
+		return fmt.Errorf("cannot scan %%T into %s", src)
 	}
 }
-`, s.typeName)
-	if s.stringToValueMapName != "" {
+
+func (i *%s) scanName(name string) error {
+	v, ok := %s[name]
+	if !ok {
+		return fmt.Errorf("unknown %s value %%q", name)
+	}
+	*i = v
+	return nil
+}
+
+func (i *%s) scanValue(v %s) error {
+	for _, known := range %s {
+		if known == v {
+			*i = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s value %%d", int64(v))
+}
+`, s.typeName, s.typeName, nameToValueMapName, s.typeName, s.typeName, s.typeName, enumValuesSliceName, s.typeName)
+}
+
+// writeParseFunc writes a Parse<Type> function doing a lookup against
+// nameToValueMapName, case-sensitively unless --caseinsensitive was given.
+func (s tinyStringer) writeParseFunc(outputFile *os.File, nameToValueMapName string) {
+	if s.caseInsensitive {
 		fmt.Fprintf(outputFile, `
-var %s = map[string]%s{
-`, s.stringToValueMapName, s.typeName)
-		// Figure out the length of the longest const name to see how
-		// much we need to pad it out.
-		var maxLen int
-		for _, constName := range inOrder {
-			if len(nameToPrinted[constName]) > maxLen {
-				maxLen = len(nameToPrinted[constName])
-			}
+func Parse%s(s string) (%s, error) {
+	s = strings.ToLower(s)
+	for name, v := range %s {
+		if strings.ToLower(name) == s {
+			return v, nil
 		}
-		for _, constName := range inOrder {
-			if constName == "_" {
-				continue
-			}
-			padding := strings.Repeat(" ", 1+maxLen-len(nameToPrinted[constName]))
-			fmt.Fprintf(outputFile, `	"%s":%s%d,
-`, nameToPrinted[constName], padding, nameToInt[constName])
+	}
+	return 0, fmt.Errorf("unknown %s value %%q", s)
+}
+`, s.typeName, s.typeName, nameToValueMapName, s.typeName)
+		return
+	}
+	fmt.Fprintf(outputFile, `
+func Parse%s(s string) (%s, error) {
+	v, ok := %s[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown %s value %%q", s)
+	}
+	return v, nil
+}
+`, s.typeName, s.typeName, nameToValueMapName, s.typeName)
+}
+
+// writeExhaustiveCheck writes a blank-identifier map literal listing every
+// known constant. An "undefined identifier" compiler error signifies that a
+// constant was renamed or removed; re-run the stringer command to pick up
+// the change. Unlike the invalid-array-index trick in func _(), which keys
+// on values, this one keys on the set of names.
+func (s tinyStringer) writeExhaustiveCheck(outputFile *os.File, inOrder []string, nameToInt map[string]int) {
+	fmt.Fprintf(outputFile, `
+var _ = map[%s]struct{}{
+`, s.typeName)
+	// A map literal can't repeat the same value as a key twice, even under
+	// different constant names, so dedupe the same way the other tables do.
+	seen := make(map[int]struct{})
+	for _, constName := range inOrder {
+		if constName == "_" {
+			continue
 		}
-		fmt.Fprintf(outputFile, `}
+		if _, ok := seen[nameToInt[constName]]; ok {
+			continue
+		}
+		seen[nameToInt[constName]] = struct{}{}
+		fmt.Fprintf(outputFile, `	%s: {},
+`, constName)
+	}
+	fmt.Fprintf(outputFile, `}
 `)
+}
+
+// writeBitflagsString writes a String() body and its closing brace (the
+// receiver declaration and opening brace have already been emitted) that
+// decomposes i into the
+// known single-bit constants it's built from, joined with "|", e.g.
+// "FlagA|FlagC". Bits that don't correspond to a known single-bit constant
+// are rendered using the same "Type(N)" format as the default case of a
+// plain, non-bitflags String().
+func (s tinyStringer) writeBitflagsString(
+	outputFile *os.File,
+	receiverVar string,
+	inOrder []string,
+	nameToInt map[string]int,
+	nameToPrinted map[string]string,
+) {
+	if zeroName, ok := zeroValueConstName(inOrder, nameToInt); ok {
+		fmt.Fprintf(outputFile, `	if %s == 0 {
+		return "%s"
 	}
-	if s.enumValuesSliceName != "" {
-		seen := make(map[int]struct{})
-		fmt.Fprintf(outputFile, `
-var %s = []%s{
-`, s.enumValuesSliceName, s.typeName)
-		inLexicographicOrder := make([]string, len(inOrder))
-		copy(inLexicographicOrder, inOrder)
-		// Clear duplicates, select the first one in order.
-		i := 0
-		for i < len(inLexicographicOrder) {
-			constName := inLexicographicOrder[i]
-			if _, ok := seen[nameToInt[constName]]; ok {
-				inLexicographicOrder = append(inLexicographicOrder[:i], inLexicographicOrder[i+1:]...)
-			} else {
-				i += 1
-				seen[nameToInt[constName]] = struct{}{}
-			}
+`, receiverVar, nameToPrinted[zeroName])
+	}
+	fmt.Fprintf(outputFile, `	var parts []string
+	remaining := %s
+`, receiverVar)
+	seen := make(map[int]struct{})
+	for _, constName := range inOrder {
+		if constName == "_" {
+			continue
 		}
-		slices.SortFunc(inLexicographicOrder, func(a, b string) int {
-			return cmp.Compare(nameToPrinted[a], nameToPrinted[b])
-		})
-		seen = make(map[int]struct{})
-		for _, constName := range inLexicographicOrder {
-			if constName == "_" {
-				continue
-			}
-			if _, ok := seen[nameToInt[constName]]; ok {
-				continue
-			}
-			fmt.Fprintf(outputFile, `	%s,
-`, constName)
-			seen[nameToInt[constName]] = struct{}{}
+		v := nameToInt[constName]
+		if v == 0 || v&(v-1) != 0 {
+			// Not a single-bit value; only single-bit constants participate
+			// in the decomposition.
+			continue
 		}
-		fmt.Fprintf(outputFile, `}
-`)
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		fmt.Fprintf(outputFile, `	if remaining&%s != 0 {
+		parts = append(parts, "%s")
+		remaining &^= %s
+	}
+`, constName, nameToPrinted[constName], constName)
 	}
+	fmt.Fprintf(outputFile, `	if remaining != 0 {
+		parts = append(parts, "%s("+strconv.FormatInt(int64(remaining), 10)+")")
+	}
+	if len(parts) == 0 {
+		return "%s(0)"
+	}
+	return strings.Join(parts, "|")
+}
+`, s.typeName, s.typeName)
+}
 
-	return nil
+// zeroValueConstName returns the name of the first constant in inOrder whose
+// value is zero, if any. Bitflag enums often declare an explicit "none of
+// the above" constant equal to zero, which String() should print by name
+// rather than falling through the bit-decomposition loop (which produces no
+// parts for a zero receiver).
+func zeroValueConstName(inOrder []string, nameToInt map[string]int) (string, bool) {
+	for _, constName := range inOrder {
+		if constName == "_" {
+			continue
+		}
+		if nameToInt[constName] == 0 {
+			return constName, true
+		}
+	}
+	return "", false
 }
 
 // parseAllFiles returns a list of all the files parsed, the name of the package, and an error if one occurred.
@@ -312,7 +791,7 @@ func validateType(files []*ast.File, typeName string) error {
 }
 
 func (s tinyStringer) computeConstantValues(
-	files []*ast.File,
+	files []*ast.File, typeName string,
 ) (inOrder []string, nameToInt map[string]int, nameToPrinted map[string]string, err error) {
 	nameToInt = make(map[string]int)
 	nameToPrinted = make(map[string]string)
@@ -328,8 +807,13 @@ func (s tinyStringer) computeConstantValues(
 				continue
 			}
 			var inferAppropriateType, inIota bool
-			var iotaVal int
-			for _, spec := range genDecl.Specs {
+			var repeatExpr ast.Expr
+			// iotaVal tracks the spec's 0-based position within genDecl.Specs,
+			// matching real Go's iota semantics: it advances once per
+			// ConstSpec in the block regardless of whether that spec's value
+			// expression mentions iota (e.g. a leading `= 0` sentinel before
+			// a `1 << iota` sequence still occupies position 0).
+			for iotaVal, spec := range genDecl.Specs {
 				valueSpec, ok := spec.(*ast.ValueSpec)
 				if !ok {
 					// Should never happen.
@@ -340,13 +824,13 @@ func (s tinyStringer) computeConstantValues(
 					continue
 				}
 				ident, ok := valueSpec.Type.(*ast.Ident)
-				if (ok && ident.Name != s.typeName) || (!ok && !inferAppropriateType) {
+				if (ok && ident.Name != typeName) || (!ok && !inferAppropriateType) {
 					inferAppropriateType = false
 					continue
 				}
 				inferAppropriateType = true
 				if len(valueSpec.Names) != 1 {
-					err = fmt.Errorf("expected one name for constant of type %s; found %+v", s.typeName, valueSpec.Names)
+					err = fmt.Errorf("expected one name for constant of type %s; found %+v", typeName, valueSpec.Names)
 					return
 				}
 				constName := valueSpec.Names[0].Name
@@ -354,8 +838,12 @@ func (s tinyStringer) computeConstantValues(
 				// Check the value to see what value we'll assign to the constant.
 				if valueSpec.Values == nil {
 					if inIota {
-						nameToInt[constName] = iotaVal
-						iotaVal += 1
+						var val int
+						val, _, err = evalIotaExpr(repeatExpr, iotaVal, nameToInt)
+						if err != nil {
+							return
+						}
+						nameToInt[constName] = val
 					} else {
 						nameToInt[constName] = 0
 					}
@@ -386,47 +874,48 @@ func (s tinyStringer) computeConstantValues(
 						err = fmt.Errorf("expected integer value for constant %s; found %s", constName, lit.Value)
 						return
 					}
+					repeatExpr = nil
 				} else if ident, ok := valueSpec.Values[0].(*ast.Ident); ok {
 					if ident.Name == "iota" {
 						inIota = true
+						repeatExpr = ident
 						nameToInt[constName] = iotaVal
-						iotaVal += 1
 					} else if otherValue, ok := nameToInt[ident.Name]; ok {
 						nameToInt[constName] = otherValue
 						inIota = false
+						repeatExpr = nil
 					}
-				} else if binExpr, ok := valueSpec.Values[0].(*ast.BinaryExpr); ok {
-					// Handle iota + N or iota - N.
-					iotaIdent, ok := binExpr.X.(*ast.Ident)
-					if !ok || iotaIdent.Name != "iota" {
-						err = fmt.Errorf("expected 'iota' in binary expression %+v; found %+v", binExpr, binExpr.X)
+				} else if _, ok := valueSpec.Values[0].(*ast.BinaryExpr); ok {
+					// Handle arbitrary combinations of iota with the binary
+					// operators Go enum declarations commonly use: iota + N,
+					// iota - N, iota * N, 1 << iota, 1 << (iota + N), etc.
+					var val int
+					var usesIota bool
+					val, usesIota, err = evalIotaExpr(valueSpec.Values[0], iotaVal, nameToInt)
+					if err != nil {
 						return
 					}
-					var otherNumParsed int64
-					if otherNum, ok := binExpr.Y.(*ast.BasicLit); ok && otherNum.Kind == token.INT {
-						otherNumParsed, err = strconv.ParseInt(otherNum.Value, 0, 0)
-						if err != nil {
-							return
-						}
-					} else if otherRef, ok := binExpr.Y.(*ast.Ident); ok {
-						otherNum, ok := nameToInt[otherRef.Name]
-						if !ok {
-							err = fmt.Errorf("could not find value of %s", otherRef.Name)
-							return
-						}
-						otherNumParsed = int64(otherNum)
+					nameToInt[constName] = val
+					inIota = usesIota
+					if usesIota {
+						repeatExpr = valueSpec.Values[0]
 					} else {
-						err = fmt.Errorf("couldn't parse second argument of binary expression %+v; found %+v", binExpr, binExpr.Y)
+						repeatExpr = nil
+					}
+				} else if parenExpr, ok := valueSpec.Values[0].(*ast.ParenExpr); ok {
+					var val int
+					var usesIota bool
+					val, usesIota, err = evalIotaExpr(parenExpr, iotaVal, nameToInt)
+					if err != nil {
 						return
 					}
-					if binExpr.Op == token.ADD {
-						iotaVal = iotaVal + int(otherNumParsed)
-					} else if binExpr.Op == token.SUB {
-						iotaVal = iotaVal - int(otherNumParsed)
+					nameToInt[constName] = val
+					inIota = usesIota
+					if usesIota {
+						repeatExpr = parenExpr
+					} else {
+						repeatExpr = nil
 					}
-					inIota = true
-					nameToInt[constName] = iotaVal
-					iotaVal += 1
 				} else {
 					err = fmt.Errorf("don't know how to process %+v", valueSpec.Values[0])
 					return
@@ -446,3 +935,61 @@ func (s tinyStringer) computeConstantValues(
 	}
 	return
 }
+
+// evalIotaExpr evaluates a constant expression made up of iota, integer
+// literals, references to previously computed constants, parenthesized
+// sub-expressions, and the binary operators Go enum declarations commonly
+// build flag values with (+, -, *, <<, >>), given the current value of iota
+// for this spec. It reports whether the expression mentions iota so the
+// caller knows whether this spec participates in an iota sequence (and thus
+// whether later specs with no value should repeat it at the next iota
+// value).
+func evalIotaExpr(expr ast.Expr, iotaVal int, nameToInt map[string]int) (val int, usesIota bool, err error) {
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		return evalIotaExpr(e.X, iotaVal, nameToInt)
+	case *ast.Ident:
+		if e.Name == "iota" {
+			return iotaVal, true, nil
+		}
+		other, ok := nameToInt[e.Name]
+		if !ok {
+			return 0, false, fmt.Errorf("could not find value of %s", e.Name)
+		}
+		return other, false, nil
+	case *ast.BasicLit:
+		if e.Kind != token.INT {
+			return 0, false, fmt.Errorf("expected integer literal; found %+v", e)
+		}
+		intVal, parseErr := strconv.ParseInt(e.Value, 0, 0)
+		if parseErr != nil {
+			return 0, false, parseErr
+		}
+		return int(intVal), false, nil
+	case *ast.BinaryExpr:
+		x, xUsesIota, xErr := evalIotaExpr(e.X, iotaVal, nameToInt)
+		if xErr != nil {
+			return 0, false, xErr
+		}
+		y, yUsesIota, yErr := evalIotaExpr(e.Y, iotaVal, nameToInt)
+		if yErr != nil {
+			return 0, false, yErr
+		}
+		switch e.Op {
+		case token.ADD:
+			return x + y, xUsesIota || yUsesIota, nil
+		case token.SUB:
+			return x - y, xUsesIota || yUsesIota, nil
+		case token.MUL:
+			return x * y, xUsesIota || yUsesIota, nil
+		case token.SHL:
+			return x << uint(y), xUsesIota || yUsesIota, nil
+		case token.SHR:
+			return x >> uint(y), xUsesIota || yUsesIota, nil
+		default:
+			return 0, false, fmt.Errorf("unsupported operator %s in binary expression %+v", e.Op, e)
+		}
+	default:
+		return 0, false, fmt.Errorf("don't know how to evaluate expression %+v", expr)
+	}
+}